@@ -0,0 +1,246 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+var cassandraDatacenterGVR = schema.GroupVersionResource{
+	Group:    "cassandra.datastax.com",
+	Version:  "v1beta1",
+	Resource: "cassandradatacenters",
+}
+
+var (
+	diagClientsOnce sync.Once
+	diagClientset   *kubernetes.Clientset
+	diagDynamic     dynamic.Interface
+	diagClientErr   error
+)
+
+// diagClients lazily builds the client-go clients used for diagnostics
+// dumps, reusing the same kubeconfig resolution rules as kubectl (honoring
+// KUBECONFIG, falling back to ~/.kube/config).
+func diagClients() (*kubernetes.Clientset, dynamic.Interface, error) {
+	diagClientsOnce.Do(func() {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			diagClientErr = err
+			return
+		}
+
+		diagClientset, diagClientErr = kubernetes.NewForConfig(config)
+		if diagClientErr != nil {
+			return
+		}
+		diagDynamic, diagClientErr = dynamic.NewForConfig(config)
+	})
+
+	return diagClientset, diagDynamic, diagClientErr
+}
+
+// DumpDiagnosticsOnFailure gathers a much richer snapshot of cluster state
+// than the plain `kubectl logs` capture that ExecAndLog/WaitForOutputAndLog
+// perform on every step when EnvAlwaysDumpLogs is set: CassandraDatacenter CRs, full pod status (including
+// restart counts and last-termination messages), events, StatefulSets, PVCs,
+// and the cass-operator deployment's own logs. Each is written as its own
+// file under the step's log dir so it's cheap to skip when nothing failed.
+// It is meant to be called once a step (or spec) is known to have failed;
+// it does the work unconditionally and is intentionally best-effort, since
+// a diagnostics collection failure shouldn't mask the real test failure.
+//
+// testLogDir must be the same step log dir the caller already computed via
+// genTestLogDir -- calling genTestLogDir again here would consume another
+// step number and point the dump at the wrong directory.
+func (ns *NsWrapper) DumpDiagnosticsOnFailure(testLogDir string) {
+	clientset, dyn, err := diagClients()
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not build client-go clients: %v\n", err)
+		return
+	}
+
+	dir := testLogDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not create %s: %v\n", dir, err)
+		return
+	}
+
+	ns.dumpCassandraDatacenters(dyn, dir)
+	ns.dumpPods(clientset, dir)
+	ns.dumpEvents(clientset, dir)
+	ns.dumpStatefulSets(clientset, dir)
+	ns.dumpPVCs(clientset, dir)
+	ns.dumpOperatorLogs(clientset, dir)
+}
+
+func writeDiagFile(dir, name string, data []byte) {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not write %s: %v\n", path, err)
+	}
+}
+
+func (ns *NsWrapper) dumpCassandraDatacenters(dyn dynamic.Interface, dir string) {
+	list, err := dyn.Resource(cassandraDatacenterGVR).Namespace(ns.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not list CassandraDatacenters: %v\n", err)
+		return
+	}
+
+	for _, dc := range list.Items {
+		out, err := yaml.Marshal(dc.Object)
+		if err != nil {
+			fmt.Printf("DumpDiagnosticsOnFailure: could not marshal CassandraDatacenter %s: %v\n", dc.GetName(), err)
+			continue
+		}
+		writeDiagFile(dir, fmt.Sprintf("cassandradatacenter_%s.yaml", dc.GetName()), out)
+	}
+}
+
+func (ns *NsWrapper) dumpPods(clientset *kubernetes.Clientset, dir string) {
+	pods, err := clientset.CoreV1().Pods(ns.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not list pods: %v\n", err)
+		return
+	}
+
+	var summary []byte
+	for _, pod := range pods.Items {
+		summary = append(summary, []byte(fmt.Sprintf("pod/%s phase=%s\n", pod.Name, pod.Status.Phase))...)
+		for _, cs := range pod.Status.ContainerStatuses {
+			summary = append(summary, []byte(fmt.Sprintf(
+				"  container=%s ready=%t restarts=%d lastTerminated=%q\n",
+				cs.Name, cs.Ready, cs.RestartCount, lastTerminationMessage(cs)))...)
+		}
+
+		out, err := yaml.Marshal(pod.Status)
+		if err != nil {
+			continue
+		}
+		writeDiagFile(dir, fmt.Sprintf("pod_%s_status.yaml", pod.Name), out)
+
+		if podHasCrashedContainer(pod) {
+			prevLogs := ns.fetchPodLogs(clientset, pod.Name, true)
+			writeDiagFile(dir, fmt.Sprintf("pod_%s_previous.log", pod.Name), prevLogs)
+		}
+	}
+	writeDiagFile(dir, "pods_summary.txt", summary)
+}
+
+func lastTerminationMessage(cs corev1.ContainerStatus) string {
+	if cs.LastTerminationState.Terminated == nil {
+		return ""
+	}
+	return cs.LastTerminationState.Terminated.Message
+}
+
+func podHasCrashedContainer(pod corev1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.RestartCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (ns *NsWrapper) fetchPodLogs(clientset *kubernetes.Clientset, podName string, previous bool) []byte {
+	req := clientset.CoreV1().Pods(ns.Namespace).GetLogs(podName, &corev1.PodLogOptions{Previous: previous})
+	stream, err := req.Stream()
+	if err != nil {
+		return []byte(fmt.Sprintf("could not fetch logs for pod %s (previous=%t): %v", podName, previous, err))
+	}
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return []byte(fmt.Sprintf("could not read logs for pod %s (previous=%t): %v", podName, previous, err))
+	}
+	return data
+}
+
+func (ns *NsWrapper) dumpEvents(clientset *kubernetes.Clientset, dir string) {
+	events, err := clientset.CoreV1().Events(ns.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not list events: %v\n", err)
+		return
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Before(&items[j].LastTimestamp)
+	})
+
+	var out []byte
+	for _, e := range items {
+		out = append(out, []byte(fmt.Sprintf("%s %s/%s %s: %s\n",
+			e.LastTimestamp.Format("2006-01-02T15:04:05Z"), e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Reason, e.Message))...)
+	}
+	writeDiagFile(dir, "events.txt", out)
+}
+
+func (ns *NsWrapper) dumpStatefulSets(clientset *kubernetes.Clientset, dir string) {
+	sts, err := clientset.AppsV1().StatefulSets(ns.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not list statefulsets: %v\n", err)
+		return
+	}
+	for _, s := range sts.Items {
+		out, err := yaml.Marshal(s)
+		if err != nil {
+			continue
+		}
+		writeDiagFile(dir, fmt.Sprintf("statefulset_%s.yaml", s.Name), out)
+	}
+}
+
+func (ns *NsWrapper) dumpPVCs(clientset *kubernetes.Clientset, dir string) {
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(ns.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not list pvcs: %v\n", err)
+		return
+	}
+	for _, p := range pvcs.Items {
+		out, err := yaml.Marshal(p)
+		if err != nil {
+			continue
+		}
+		writeDiagFile(dir, fmt.Sprintf("pvc_%s.yaml", p.Name), out)
+	}
+}
+
+func (ns *NsWrapper) dumpOperatorLogs(clientset *kubernetes.Clientset, dir string) {
+	pods, err := clientset.CoreV1().Pods(ns.Namespace).List(metav1.ListOptions{
+		LabelSelector: "name=cass-operator",
+	})
+	if err != nil || len(pods.Items) == 0 {
+		fmt.Printf("DumpDiagnosticsOnFailure: could not find cass-operator pod: %v\n", err)
+		return
+	}
+
+	for _, pod := range pods.Items {
+		logs := ns.fetchPodLogs(clientset, pod.Name, false)
+		writeDiagFile(dir, fmt.Sprintf("operator_%s.log", pod.Name), logs)
+
+		if podHasCrashedContainer(pod) {
+			prevLogs := ns.fetchPodLogs(clientset, pod.Name, true)
+			writeDiagFile(dir, fmt.Sprintf("operator_%s_previous.log", pod.Name), prevLogs)
+		}
+	}
+}
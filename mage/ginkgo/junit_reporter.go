@@ -0,0 +1,124 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// junitTestSuites/junitTestSuite/junitTestCase mirror the subset of the
+// JUnit XML schema understood by Jenkins, GitHub Actions and Polarion's
+// importer, so that every NsWrapper suite can be surfaced in CI without
+// any downstream tooling needing to grep per-step log dirs.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	XMLName   xml.Name      `xml:"testcase"`
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:",chardata"`
+}
+
+// JUnitReporter accumulates one testcase entry per logged NsWrapper step
+// (ExecAndLog, OutputAndLog, the WaitFor*AndLog family, and plain Ginkgo
+// Bys) and flushes them to a JUnit-compatible XML file next to the
+// suite's kubectl_dump log tree, so CI can surface exactly which e2e step
+// failed and historical runs can be mined for flakiness.
+type JUnitReporter struct {
+	mu        sync.Mutex
+	suiteName string
+	cases     []junitTestCase
+}
+
+func newJUnitReporter(suiteName string) *JUnitReporter {
+	return &JUnitReporter{suiteName: suiteName}
+}
+
+// recordStep appends a testcase entry for a single step. dumpLogsDir is
+// only meaningful (and only surfaced in the failure message) when err is
+// non-nil, pointing at the per-step DumpLogs directory for that step.
+func (r *JUnitReporter) recordStep(desc, command string, start time.Time, stdout string, err error, dumpLogsDir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tc := junitTestCase{
+		Name:      desc,
+		ClassName: r.suiteName,
+		Time:      time.Since(start).Seconds(),
+	}
+	if command != "" {
+		tc.SystemOut = fmt.Sprintf("$ %s\n%s", command, stdout)
+	} else {
+		tc.SystemOut = stdout
+	}
+	if err != nil {
+		tc.Failure = &junitFailure{
+			Type:    "StepFailure",
+			Message: fmt.Sprintf("%s\n\nDumpLogs: %s", err.Error(), dumpLogsDir),
+		}
+	}
+
+	r.cases = append(r.cases, tc)
+}
+
+// WriteXML renders everything recorded so far as a single <testsuites>
+// document and writes it to path, creating parent directories as needed.
+func (r *JUnitReporter) WriteXML(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures := 0
+	var total float64
+	for _, tc := range r.cases {
+		if tc.Failure != nil {
+			failures++
+		}
+		total += tc.Time
+	}
+
+	suite := junitTestSuite{
+		Name:      r.suiteName,
+		Tests:     len(r.cases),
+		Failures:  failures,
+		Time:      total,
+		TestCases: r.cases,
+	}
+
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append([]byte(xml.Header), out...), 0644)
+}
@@ -21,17 +21,24 @@ import (
 
 const (
 	EnvNoCleanup = "M_NO_CLEANUP"
-)
 
-func duplicate(value string, count int) string {
-	result := []string{}
-	for i := 0; i < count; i++ {
-		result = append(result, value)
-	}
+	// EnvAlwaysDumpLogs opts a suite into the lightweight kubectl-based log
+	// capture on every step, not just on failure. The heavier client-go
+	// diagnostics dump (DumpDiagnosticsOnFailure) always runs on failure
+	// regardless of this flag.
+	EnvAlwaysDumpLogs = "M_ALWAYS_DUMP_LOGS"
+)
 
-	return strings.Join(result, " ")
+func alwaysDumpLogs() bool {
+	return strings.ToLower(os.Getenv(EnvAlwaysDumpLogs)) == "true"
 }
 
+// renderCommand renders kcmd as the `kubectl ...` command line it actually
+// runs, for use in step logging -- kcmd itself has no Stringer, so
+// formatting it with %v would just dump the KCmd struct.
+func renderCommand(kcmd kubectl.KCmd) string {
+	return strings.Join(append([]string{"kubectl"}, kcmd.ToCliArgs()...), " ")
+}
 
 // Wrapper type to make it simpler to
 // set a namespace one time and execute all of your
@@ -42,16 +49,49 @@ type NsWrapper struct {
 	TestSuiteName string
 	LogDir        string
 	stepCounter   int
+	Reporter      *JUnitReporter
 }
 
 func NewWrapper(suiteName string, namespace string) NsWrapper {
-	return NsWrapper{
+	ns := NsWrapper{
 		Namespace:     namespace,
 		TestSuiteName: suiteName,
 		LogDir:        genSuiteLogDir(suiteName),
 		stepCounter:   1,
+		Reporter:      newJUnitReporter(suiteName),
 	}
 
+	// Flush automatically so suites get a junit.xml without remembering to
+	// wire it into their own AfterSuite. NewWrapper always runs during
+	// Ginkgo's tree-construction phase, so registering AfterSuite here is
+	// as valid as a suite calling it directly.
+	ginkgo.AfterSuite(func() {
+		if err := ns.WriteJUnitReport(); err != nil {
+			fmt.Printf("WriteJUnitReport: could not write junit.xml: %v\n", err)
+		}
+	})
+
+	return ns
+}
+
+// WriteJUnitReport flushes every step recorded so far to a JUnit-compatible
+// XML file in this suite's log dir. NewWrapper registers this against
+// ginkgo.AfterSuite automatically; call it directly only if a suite needs
+// an additional or earlier flush.
+func (ns *NsWrapper) WriteJUnitReport() error {
+	return ns.Reporter.WriteXML(fmt.Sprintf("%s/junit.xml", ns.LogDir))
+}
+
+// By records a plain Ginkgo step -- one with no associated kubectl command,
+// unlike ExecAndLog/OutputAndLog/WaitFor*AndLog -- in both Ginkgo's own
+// reporter and the JUnit report. Use this in place of ginkgo.By when a
+// step's entry should show up in junit.xml. Since a plain step has no
+// command result to inspect, it's always recorded as passing; a failing
+// assertion inside the same step still aborts the spec via Ginkgo's normal
+// failure handling, it just won't retroactively mark this testcase failed.
+func (ns *NsWrapper) By(desc string) {
+	ginkgo.By(desc)
+	ns.Reporter.recordStep(desc, "", time.Now(), "", nil, "")
 }
 
 func (k NsWrapper) ExecV(kcmd kubectl.KCmd) error {
@@ -76,25 +116,42 @@ func (k NsWrapper) OutputPanic(kcmd kubectl.KCmd) string {
 }
 
 func (k NsWrapper) WaitForOutput(kcmd kubectl.KCmd, expected string, seconds int) error {
-	return kubectl.WaitForOutput(kcmd.InNamespace(k.Namespace), expected, seconds)
+	return kubectl.WaitForOutput(kcmd.InNamespace(k.Namespace), expected, scaleSeconds(seconds))
+}
+
+// WaitForOutputD is a time.Duration-typed equivalent of WaitForOutput.
+func (k NsWrapper) WaitForOutputD(kcmd kubectl.KCmd, expected string, timeout time.Duration) error {
+	return k.WaitForOutput(kcmd, expected, int(timeout/time.Second))
 }
 
 func (k NsWrapper) WaitForOutputContains(kcmd kubectl.KCmd, expected string, seconds int) error {
-	return kubectl.WaitForOutputContains(kcmd.InNamespace(k.Namespace), expected, seconds)
+	return kubectl.WaitForOutputContains(kcmd.InNamespace(k.Namespace), expected, scaleSeconds(seconds))
+}
+
+// WaitForOutputContainsD is a time.Duration-typed equivalent of
+// WaitForOutputContains.
+func (k NsWrapper) WaitForOutputContainsD(kcmd kubectl.KCmd, expected string, timeout time.Duration) error {
+	return k.WaitForOutputContains(kcmd, expected, int(timeout/time.Second))
 }
 
 func (k NsWrapper) WaitForOutputPanic(kcmd kubectl.KCmd, expected string, seconds int) {
-	err := kubectl.WaitForOutput(kcmd.InNamespace(k.Namespace), expected, seconds)
+	err := kubectl.WaitForOutput(kcmd.InNamespace(k.Namespace), expected, scaleSeconds(seconds))
 	Expect(err).ToNot(HaveOccurred())
 }
 
 func (k NsWrapper) WaitForOutputContainsPanic(kcmd kubectl.KCmd, expected string, seconds int) {
-	err := kubectl.WaitForOutput(kcmd.InNamespace(k.Namespace), expected, seconds)
+	err := kubectl.WaitForOutputContains(kcmd.InNamespace(k.Namespace), expected, scaleSeconds(seconds))
 	Expect(err).ToNot(HaveOccurred())
 }
 
 func (k NsWrapper) WaitForOutputPattern(kcmd kubectl.KCmd, pattern string, seconds int) error {
-	return kubectl.WaitForOutputPattern(kcmd.InNamespace(k.Namespace), pattern, seconds)
+	return kubectl.WaitForOutputPattern(kcmd.InNamespace(k.Namespace), pattern, scaleSeconds(seconds))
+}
+
+// WaitForOutputPatternD is a time.Duration-typed equivalent of
+// WaitForOutputPattern.
+func (k NsWrapper) WaitForOutputPatternD(kcmd kubectl.KCmd, pattern string, timeout time.Duration) error {
+	return k.WaitForOutputPattern(kcmd, pattern, int(timeout/time.Second))
 }
 
 func (k *NsWrapper) countStep() int {
@@ -103,24 +160,6 @@ func (k *NsWrapper) countStep() int {
 	return n
 }
 
-func (k NsWrapper) Terminate() error {
-	noCleanup := os.Getenv(EnvNoCleanup)
-	if strings.ToLower(noCleanup) == "true" {
-		fmt.Println("Skipping namespace cleanup and deletion.")
-		return nil
-	}
-
-	fmt.Println("Cleaning up and deleting namespace.")
-	// Always try to delete the dc that was used in the test
-	// incase the test failed out before a delete step.
-	//
-	// This is important because deleting the namespace itself
-	// can hang if this step is skipped.
-	kcmd := kubectl.Delete("cassandradatacenter", "--all")
-	_ = k.ExecV(kcmd)
-	return kubectl.DeleteByTypeAndName("namespace", k.Namespace).ExecV()
-}
-
 //===================================
 // Logging functions for the NsWrapper
 // that execute the Kcmd and then dump
@@ -145,68 +184,85 @@ func (ns *NsWrapper) genTestLogDir(description string) string {
 
 func (ns *NsWrapper) ExecAndLog(description string, kcmd kubectl.KCmd) {
 	ginkgo.By(description)
-	defer kubectl.DumpLogs(ns.genTestLogDir(description), ns.Namespace).ExecVPanic()
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(description)
+	if alwaysDumpLogs() {
+		defer kubectl.DumpLogs(testLogDir, ns.Namespace).ExecVPanic()
+	}
 	execErr := ns.ExecV(kcmd)
+	if execErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(description, renderCommand(kcmd), start, "", execErr, testLogDir)
 	Expect(execErr).ToNot(HaveOccurred())
 }
 
 func (ns *NsWrapper) OutputAndLog(description string, kcmd kubectl.KCmd) string {
 	ginkgo.By(description)
-	defer kubectl.DumpLogs(ns.genTestLogDir(description), ns.Namespace).ExecVPanic()
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(description)
+	if alwaysDumpLogs() {
+		defer kubectl.DumpLogs(testLogDir, ns.Namespace).ExecVPanic()
+	}
 	output, execErr := ns.Output(kcmd)
+	if execErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(description, renderCommand(kcmd), start, output, execErr, testLogDir)
 	Expect(execErr).ToNot(HaveOccurred())
 	return output
 }
 
 func (ns *NsWrapper) WaitForOutputAndLog(description string, kcmd kubectl.KCmd, expected string, seconds int) {
 	ginkgo.By(description)
-	defer kubectl.DumpLogs(ns.genTestLogDir(description), ns.Namespace).ExecVPanic()
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(description)
+	if alwaysDumpLogs() {
+		defer kubectl.DumpLogs(testLogDir, ns.Namespace).ExecVPanic()
+	}
 	execErr := ns.WaitForOutput(kcmd, expected, seconds)
+	// Fetch what the command actually produced, for the JUnit record --
+	// `expected` is only what we hoped to see.
+	lastOutput, _ := ns.Output(kcmd)
+	if execErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(description, renderCommand(kcmd), start, lastOutput, execErr, testLogDir)
 	Expect(execErr).ToNot(HaveOccurred())
 }
 
 func (ns *NsWrapper) WaitForOutputPatternAndLog(description string, kcmd kubectl.KCmd, expected string, seconds int) {
 	ginkgo.By(description)
-	defer kubectl.DumpLogs(ns.genTestLogDir(description), ns.Namespace).ExecVPanic()
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(description)
+	if alwaysDumpLogs() {
+		defer kubectl.DumpLogs(testLogDir, ns.Namespace).ExecVPanic()
+	}
 	execErr := ns.WaitForOutputPattern(kcmd, expected, seconds)
+	lastOutput, _ := ns.Output(kcmd)
+	if execErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(description, renderCommand(kcmd), start, lastOutput, execErr, testLogDir)
 	Expect(execErr).ToNot(HaveOccurred())
 }
 
 func (ns *NsWrapper) WaitForOutputContainsAndLog(description string, kcmd kubectl.KCmd, expected string, seconds int) {
 	ginkgo.By(description)
-	defer kubectl.DumpLogs(ns.genTestLogDir(description), ns.Namespace).ExecVPanic()
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(description)
+	if alwaysDumpLogs() {
+		defer kubectl.DumpLogs(testLogDir, ns.Namespace).ExecVPanic()
+	}
 	execErr := ns.WaitForOutputContains(kcmd, expected, seconds)
+	lastOutput, _ := ns.Output(kcmd)
+	if execErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(description, renderCommand(kcmd), start, lastOutput, execErr, testLogDir)
 	Expect(execErr).ToNot(HaveOccurred())
 }
 
-func (ns *NsWrapper) WaitForDatacenterToHaveNoPods(dcName string) {
-	step := "checking that no dc pods remain"
-	json := "jsonpath={.items}"
-	k := kubectl.Get("pods").
-		WithLabel(fmt.Sprintf("cassandra.datastax.com/datacenter=%s", dcName)).
-		FormatOutput(json)
-	ns.WaitForOutputAndLog(step, k, "[]", 300)
-}
-
-func (ns *NsWrapper) WaitForDatacenterOperatorProgress(dcName string, progressValue string, timeout int) {
-	step := fmt.Sprintf("checking the cassandra operator progress status is set to %s", progressValue)
-	json := "jsonpath={.status.cassandraOperatorProgress}"
-	k := kubectl.Get("CassandraDatacenter", dcName).
-		FormatOutput(json)
-	ns.WaitForOutputAndLog(step, k, progressValue, timeout)
-}
-
-func (ns *NsWrapper) WaitForDatacenterReadyPodCount(dcName string, count int) {
-	timeout := count * 400
-	step := "waiting for the node to become ready"
-	json := "jsonpath={.items[*].status.containerStatuses[0].ready}"
-	k := kubectl.Get("pods").
-		WithLabel(fmt.Sprintf("cassandra.datastax.com/datacenter=%s", dcName)).
-		WithFlag("field-selector", "status.phase=Running").
-		FormatOutput(json)
-	ns.WaitForOutputAndLog(step, k, duplicate("true", count), timeout)
-}
-
 func (ns *NsWrapper) WaitForDatacenterReady(dcName string) {
 	json := "jsonpath={.spec.size}"
 	k := kubectl.Get("CassandraDatacenter", dcName).FormatOutput(json)
@@ -218,34 +274,6 @@ func (ns *NsWrapper) WaitForDatacenterReady(dcName string) {
 	ns.WaitForDatacenterOperatorProgress(dcName, "Ready", 30)
 }
 
-func (ns *NsWrapper) WaitForPodNotStarted(podName string) {
-	step := "verify that the pod is no longer marked as started"
-	k := kubectl.Get("pod").
-		WithFlag("field-selector", "metadata.name="+podName).
-		WithFlag("selector", "cassandra.datastax.com/node-state=Started")
-	ns.WaitForOutputAndLog(step, k, "", 60)
-}
-
-func (ns *NsWrapper) WaitForPodStarted(podName string) {
-	step := "verify that the pod is marked as started"
-	json := "jsonpath={.items[*].metadata.name}"
-	k := kubectl.Get("pod").
-		WithFlag("field-selector", "metadata.name="+podName).
-		WithFlag("selector", "cassandra.datastax.com/node-state=Started").
-		FormatOutput(json)
-	ns.WaitForOutputAndLog(step, k, podName, 60)
-}
-
-func (ns *NsWrapper) DisableGossipWaitNotReady(podName string) {
-	ns.DisableGossip(podName)
-	ns.WaitForPodNotStarted(podName)
-}
-
-func (ns *NsWrapper) EnableGossipWaitReady(podName string) {
-	ns.EnableGossip(podName)
-	ns.WaitForPodStarted(podName)
-}
-
 func (ns *NsWrapper) DisableGossip(podName string) {
 	execArgs := []string{"-c", "cassandra",
 		"--", "bash", "-c",
@@ -271,6 +299,9 @@ func (ns *NsWrapper) GetDatacenterPodNames(dcName string) []string {
 		FormatOutput(json)
 
 	output := ns.OutputPanic(k)
+	if output == "" {
+		return nil
+	}
 	podNames := strings.Split(output, " ")
 	sort.Sort(sort.StringSlice(podNames))
 
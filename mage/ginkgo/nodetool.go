@@ -0,0 +1,185 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ginkgo "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/datastax/cass-operator/mage/kubectl"
+)
+
+// ExecResult is the outcome of one kubectl exec run against a single pod
+// as part of a fan-out operation.
+type ExecResult struct {
+	Output string
+	Err    error
+}
+
+// ExecOnAllDatacenterPods runs execArgs as a `kubectl exec` against every
+// pod in dcName concurrently, bounded by parallelism (parallelism <= 0
+// means unbounded), and returns each pod's result keyed by pod name. This
+// is the fan-out primitive cluster-wide nodetool operations build on,
+// instead of serializing one kubectl exec per pod.
+func (ns *NsWrapper) ExecOnAllDatacenterPods(dcName string, execArgs []string, parallelism int) map[string]ExecResult {
+	podNames := ns.GetDatacenterPodNames(dcName)
+
+	if parallelism <= 0 {
+		parallelism = len(podNames)
+	}
+	if parallelism == 0 {
+		parallelism = 1
+	}
+
+	results := make(map[string]ExecResult, len(podNames))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+
+	for _, podName := range podNames {
+		wg.Add(1)
+		go func(podName string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			out, err := ns.Output(kubectl.ExecOnPod(podName, execArgs...))
+
+			mu.Lock()
+			results[podName] = ExecResult{Output: out, Err: err}
+			mu.Unlock()
+		}(podName)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func nodetoolExecArgs(cmd string) []string {
+	return []string{"-c", "cassandra", "--", "bash", "-c", "nodetool " + cmd}
+}
+
+// DisableGossipWaitNotReady runs `nodetool disablegossip` against a single
+// pod and waits for that pod to drop out of the Started node-state.
+func (ns *NsWrapper) DisableGossipWaitNotReady(podName string) {
+	ns.DisableGossip(podName)
+	ns.WaitForPodNotStarted(podName)
+}
+
+// EnableGossipWaitReady is the DisableGossipWaitNotReady counterpart for
+// `nodetool enablegossip`.
+func (ns *NsWrapper) EnableGossipWaitReady(podName string) {
+	ns.EnableGossip(podName)
+	ns.WaitForPodStarted(podName)
+}
+
+// DisableGossipWaitNotReadyDC runs `nodetool disablegossip` across every pod
+// in dcName concurrently (bounded by parallelism) and waits for all of
+// them to drop out of the Started node-state. This unlocks cluster-wide
+// chaos scenarios, like a rolling gossip disable across a whole
+// datacenter, that were too slow to write one pod at a time.
+func (ns *NsWrapper) DisableGossipWaitNotReadyDC(dcName string, parallelism int) {
+	ns.execNodetoolWaitState(dcName, parallelism, "disablegossip", false)
+}
+
+// EnableGossipWaitReadyDC is the DisableGossipWaitNotReadyDC counterpart for
+// `nodetool enablegossip`.
+func (ns *NsWrapper) EnableGossipWaitReadyDC(dcName string, parallelism int) {
+	ns.execNodetoolWaitState(dcName, parallelism, "enablegossip", true)
+}
+
+func (ns *NsWrapper) execNodetoolWaitState(dcName string, parallelism int, nodetoolCmd string, wantStarted bool) {
+	step := fmt.Sprintf("running nodetool %s across all pods in datacenter %s", nodetoolCmd, dcName)
+	ginkgo.By(step)
+	testLogDir := ns.genTestLogDir(step)
+
+	results := ns.ExecOnAllDatacenterPods(dcName, nodetoolExecArgs(nodetoolCmd), parallelism)
+	for podName, res := range results {
+		Expect(res.Err).ToNot(HaveOccurred(), fmt.Sprintf("nodetool %s failed on pod %s: %s", nodetoolCmd, podName, res.Output))
+	}
+
+	waitErrs := make(map[string]error, len(results))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for podName := range results {
+		wg.Add(1)
+		go func(podName string) {
+			defer wg.Done()
+			err := ns.waitForPodNodeState(podName, wantStarted, 60*time.Second)
+			mu.Lock()
+			waitErrs[podName] = err
+			mu.Unlock()
+		}(podName)
+	}
+	wg.Wait()
+
+	for podName, err := range waitErrs {
+		if err != nil {
+			ns.DumpDiagnosticsOnFailure(testLogDir)
+		}
+		Expect(err).ToNot(HaveOccurred(), fmt.Sprintf("pod %s did not reach the expected node-state", podName))
+	}
+}
+
+// NodeStatus is one row of `nodetool status` output.
+type NodeStatus struct {
+	Address string
+	State   string // e.g. UN, DN, UJ, UL, UM and their Down counterparts
+	Load    string
+	Tokens  int
+	Rack    string
+}
+
+// nodetoolStatusLineRE matches one data row of `nodetool status` output.
+// Load and Owns are both "?" in common cases this parser needs to handle --
+// Owns is "?" whenever no keyspace is given (the default), and Load is "?"
+// or blank for down (DN) nodes -- so neither column can be required to hold
+// a number, and matching can't key off the Owns percent sign.
+var nodetoolStatusLineRE = regexp.MustCompile(
+	`^(U[NLJM]|D[NLJM])\s+(\S+)\s+(?:([0-9.]+\s+\S+|\?)\s+)?(\d+)\s+(?:[0-9.]+%|\?)\s+(\S+)\s+(\S+)\s*$`)
+
+// NodetoolStatus runs `nodetool status` against one pod in dcName and
+// parses the output into a typed struct per node, so tests can assert on
+// cluster topology (state, rack, token count) without ad-hoc string
+// matching against raw nodetool output.
+func (ns *NsWrapper) NodetoolStatus(dcName string) []NodeStatus {
+	podNames := ns.GetDatacenterPodNames(dcName)
+	if len(podNames) == 0 {
+		return nil
+	}
+
+	output := ns.OutputPanic(kubectl.ExecOnPod(podNames[0], nodetoolExecArgs("status")...))
+	return parseNodetoolStatus(output)
+}
+
+func parseNodetoolStatus(output string) []NodeStatus {
+	var statuses []NodeStatus
+	for _, line := range strings.Split(output, "\n") {
+		m := nodetoolStatusLineRE.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+
+		tokens, err := strconv.Atoi(m[4])
+		if err != nil {
+			continue
+		}
+
+		statuses = append(statuses, NodeStatus{
+			State:   m[1],
+			Address: m[2],
+			Load:    strings.TrimSpace(m[3]),
+			Tokens:  tokens,
+			Rack:    m[6],
+		})
+	}
+	return statuses
+}
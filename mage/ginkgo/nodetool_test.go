@@ -0,0 +1,67 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNodetoolStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   []NodeStatus
+	}{
+		{
+			name:   "up node with load and owns",
+			output: "UN  10.0.0.1  93.95 KiB  16  33.3%  abc-123  rack1",
+			want: []NodeStatus{
+				{State: "UN", Address: "10.0.0.1", Load: "93.95 KiB", Tokens: 16, Rack: "rack1"},
+			},
+		},
+		{
+			name:   "down node with unknown load",
+			output: "DN  10.0.0.2  ?  16  33.3%  abc-123  rack1",
+			want: []NodeStatus{
+				{State: "DN", Address: "10.0.0.2", Load: "?", Tokens: 16, Rack: "rack1"},
+			},
+		},
+		{
+			name:   "joining node with unknown owns (no keyspace given)",
+			output: "UJ  10.0.0.3  93.95 KiB  16  ?  abc-123  rack1",
+			want: []NodeStatus{
+				{State: "UJ", Address: "10.0.0.3", Load: "93.95 KiB", Tokens: 16, Rack: "rack1"},
+			},
+		},
+		{
+			name: "header and footer lines don't match",
+			output: "Datacenter: dc1\n" +
+				"===============\n" +
+				"Status=Up/Down\n" +
+				"|/ State=Normal/Leaving/Joining/Moving\n" +
+				"--  Address    Load       Tokens  Owns  Host ID  Rack\n" +
+				"UN  10.0.0.1   93.95 KiB  16      ?     abc-123  rack1\n",
+			want: []NodeStatus{
+				{State: "UN", Address: "10.0.0.1", Load: "93.95 KiB", Tokens: 16, Rack: "rack1"},
+			},
+		},
+		{
+			name:   "blank lines are skipped",
+			output: "UN  10.0.0.1  93.95 KiB  16  33.3%  abc-123  rack1\n\n",
+			want: []NodeStatus{
+				{State: "UN", Address: "10.0.0.1", Load: "93.95 KiB", Tokens: 16, Rack: "rack1"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseNodetoolStatus(tc.output)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseNodetoolStatus(%q) = %#v, want %#v", tc.output, got, tc.want)
+			}
+		})
+	}
+}
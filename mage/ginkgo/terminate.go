@@ -0,0 +1,200 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/datastax/cass-operator/mage/kubectl"
+)
+
+var pvcGVR = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumeclaims"}
+
+// TerminateOptions controls how aggressively Terminate tears down a
+// namespace. Cassandra pods have long termination grace periods and
+// CassandraDatacenter/PVC finalizers can get stuck, both of which leave a
+// namespace in Terminating forever if left to the default Kubernetes
+// deletion flow.
+type TerminateOptions struct {
+	// ForceAfter is how long to let the dc delete's own finalizer/grace
+	// period run before forcibly clearing pods and finalizers. Zero skips
+	// the wait entirely.
+	ForceAfter time.Duration
+
+	// StripFinalizers force-deletes pods with a zero grace period and
+	// clears finalizers on lingering CassandraDatacenter and PVC objects
+	// before the namespace itself is deleted. Set to false for tests that
+	// specifically want to exercise clean shutdown.
+	StripFinalizers bool
+}
+
+// DefaultTerminateOptions matches Terminate's historical behavior plus the
+// force-delete/finalizer-stripping that motivated this type.
+func DefaultTerminateOptions() TerminateOptions {
+	return TerminateOptions{
+		ForceAfter:      30 * time.Second,
+		StripFinalizers: true,
+	}
+}
+
+func (k NsWrapper) Terminate() error {
+	return k.TerminateWithOptions(DefaultTerminateOptions())
+}
+
+func (k NsWrapper) TerminateWithOptions(opts TerminateOptions) error {
+	noCleanup := os.Getenv(EnvNoCleanup)
+	if strings.ToLower(noCleanup) == "true" {
+		fmt.Println("Skipping namespace cleanup and deletion.")
+		return nil
+	}
+
+	fmt.Println("Cleaning up and deleting namespace.")
+	// Always try to delete the dc that was used in the test
+	// incase the test failed out before a delete step.
+	//
+	// This is important because deleting the namespace itself
+	// can hang if this step is skipped.
+	kcmd := kubectl.Delete("cassandradatacenter", "--all")
+	_ = k.ExecV(kcmd)
+
+	if opts.StripFinalizers {
+		if opts.ForceAfter <= 0 || !k.waitForCleanDelete(opts.ForceAfter) {
+			k.forceDeletePods()
+			k.stripFinalizers(cassandraDatacenterGVR)
+			k.stripFinalizers(pvcGVR)
+		}
+	}
+
+	return k.forceDeleteNamespace()
+}
+
+// waitForCleanDelete polls for up to timeout for the dc delete issued above
+// to finish on its own -- no CassandraDatacenters and no pods left in the
+// namespace -- so the common case of a clean delete doesn't pay the full
+// ForceAfter wait before TerminateWithOptions can move on. Returns true if
+// the namespace was already clean within timeout.
+func (k NsWrapper) waitForCleanDelete(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if k.dcAndPodsGone() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (k NsWrapper) dcAndPodsGone() bool {
+	clientset, dyn, err := diagClients()
+	if err != nil {
+		return false
+	}
+
+	dcs, err := dyn.Resource(cassandraDatacenterGVR).Namespace(k.Namespace).List(metav1.ListOptions{})
+	if err != nil || len(dcs.Items) > 0 {
+		return false
+	}
+
+	pods, err := clientset.CoreV1().Pods(k.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return false
+	}
+	return len(pods.Items) == 0
+}
+
+// forceDeletePods deletes every pod in the namespace with GracePeriodSeconds
+// 0, since the long Cassandra termination grace period is the usual reason
+// namespace deletion hangs in CI.
+func (k NsWrapper) forceDeletePods() {
+	clientset, _, err := diagClients()
+	if err != nil {
+		fmt.Printf("Terminate: could not build client-go clientset: %v\n", err)
+		return
+	}
+
+	pods, err := clientset.CoreV1().Pods(k.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("Terminate: could not list pods for force-delete: %v\n", err)
+		return
+	}
+
+	grace := int64(0)
+	for _, pod := range pods.Items {
+		delErr := clientset.CoreV1().Pods(k.Namespace).Delete(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: &grace})
+		if delErr != nil {
+			fmt.Printf("Terminate: could not force-delete pod %s: %v\n", pod.Name, delErr)
+		}
+	}
+}
+
+// stripFinalizers clears metadata.finalizers on every object of gvr in the
+// namespace so a stuck finalizer can't hold the namespace in Terminating.
+func (k NsWrapper) stripFinalizers(gvr schema.GroupVersionResource) {
+	_, dyn, err := diagClients()
+	if err != nil {
+		fmt.Printf("Terminate: could not build client-go dynamic client: %v\n", err)
+		return
+	}
+
+	list, err := dyn.Resource(gvr).Namespace(k.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("Terminate: could not list %s for finalizer strip: %v\n", gvr.Resource, err)
+		return
+	}
+
+	patch := []byte(`{"metadata":{"finalizers":[]}}`)
+	for _, item := range list.Items {
+		if len(item.GetFinalizers()) == 0 {
+			continue
+		}
+		_, patchErr := dyn.Resource(gvr).Namespace(k.Namespace).Patch(item.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		if patchErr != nil {
+			fmt.Printf("Terminate: could not strip finalizers from %s/%s: %v\n", gvr.Resource, item.GetName(), patchErr)
+		}
+	}
+}
+
+// namespaceDeleteTimeout bounds how long forceDeleteNamespace waits for the
+// namespace to actually disappear. Callers (e.g. back-to-back suites that
+// reuse a namespace name) rely on Terminate returning only once the
+// namespace is gone, the same contract the old blocking `kubectl delete
+// namespace` had.
+const namespaceDeleteTimeout = 120 * time.Second
+
+func (k NsWrapper) forceDeleteNamespace() error {
+	clientset, _, err := diagClients()
+	if err != nil {
+		return fmt.Errorf("Terminate: could not build client-go clientset: %w", err)
+	}
+
+	grace := int64(0)
+	if err := clientset.CoreV1().Namespaces().Delete(k.Namespace, &metav1.DeleteOptions{GracePeriodSeconds: &grace}); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(scaleTimeout(namespaceDeleteTimeout))
+	for {
+		_, err := clientset.CoreV1().Namespaces().Get(k.Namespace, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Terminate: could not check namespace %s deletion: %w", k.Namespace, err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Terminate: timed out waiting for namespace %s to be deleted", k.Namespace)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
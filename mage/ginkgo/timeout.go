@@ -0,0 +1,42 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvTimeoutScale multiplies every WaitFor* timeout in this package, so
+// slower CI runners or larger clusters can be accommodated without editing
+// every test's hardcoded timeout.
+const EnvTimeoutScale = "M_TIMEOUT_SCALE"
+
+// TimeoutScale is applied to every WaitFor* timeout via scaleTimeout. It
+// defaults to 1 (no scaling) and is read once from EnvTimeoutScale at
+// package init; set the env var before the suite starts rather than
+// mutating this var directly.
+var TimeoutScale = timeoutScaleFromEnv()
+
+func timeoutScaleFromEnv() float64 {
+	raw := os.Getenv(EnvTimeoutScale)
+	if raw == "" {
+		return 1.0
+	}
+
+	scale, err := strconv.ParseFloat(raw, 64)
+	if err != nil || scale <= 0 {
+		return 1.0
+	}
+	return scale
+}
+
+func scaleTimeout(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * TimeoutScale)
+}
+
+func scaleSeconds(seconds int) int {
+	return int(scaleTimeout(time.Duration(seconds) * time.Second) / time.Second)
+}
@@ -0,0 +1,214 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+package ginkgo_util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ginkgo "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/datastax/cass-operator/mage/k8sclient"
+)
+
+var podGVR = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+
+// waitForCondition is the shared implementation behind WaitForCondition and
+// WaitForConditionCtx: it logs desc as a Ginkgo step, watches name via gvr
+// instead of forking a `kubectl get` in a loop, records the step, and dumps
+// diagnostics on failure. timeout is scaled by TimeoutScale before use.
+func (ns *NsWrapper) waitForCondition(ctx context.Context, desc string, gvr schema.GroupVersionResource, name string, pred func(obj runtime.Object) (bool, error), timeout time.Duration) error {
+	ginkgo.By(desc)
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(desc)
+
+	_, dyn, err := diagClients()
+	if err != nil {
+		return fmt.Errorf("WaitForCondition: could not build client-go clients: %w", err)
+	}
+
+	waitErr := k8sclient.WaitForCondition(ctx, dyn, gvr, ns.Namespace, name, k8sclient.Predicate(pred), scaleTimeout(timeout))
+	if waitErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(desc, fmt.Sprintf("watch %s/%s", gvr.Resource, name), start, "", waitErr, testLogDir)
+	return waitErr
+}
+
+// WaitForCondition is the generic, watch-based wait primitive: it blocks
+// until pred is satisfied for the named object or timeout elapses. Use it
+// directly when a test needs a condition that isn't already covered by a
+// dedicated helper.
+func (ns *NsWrapper) WaitForCondition(desc string, gvr schema.GroupVersionResource, name string, pred func(obj runtime.Object) (bool, error), timeout time.Duration) error {
+	return ns.waitForCondition(context.Background(), desc, gvr, name, pred, timeout)
+}
+
+// WaitForConditionCtx is WaitForCondition with a caller-supplied context, so
+// a test's overall deadline can cancel an in-flight wait cleanly instead of
+// letting it run to its own timeout.
+func (ns *NsWrapper) WaitForConditionCtx(ctx context.Context, desc string, gvr schema.GroupVersionResource, name string, pred func(obj runtime.Object) (bool, error), timeout time.Duration) error {
+	return ns.waitForCondition(ctx, desc, gvr, name, pred, timeout)
+}
+
+// waitForListCondition is the label-selected-set analogue of
+// waitForCondition, used for conditions that depend on the whole set of
+// matching objects rather than a single named one.
+func (ns *NsWrapper) waitForListCondition(ctx context.Context, desc string, gvr schema.GroupVersionResource, listOpts metav1.ListOptions, pred func(items []unstructured.Unstructured) (bool, error), timeout time.Duration) error {
+	ginkgo.By(desc)
+	start := time.Now()
+	testLogDir := ns.genTestLogDir(desc)
+
+	_, dyn, err := diagClients()
+	if err != nil {
+		return fmt.Errorf("WaitForCondition: could not build client-go clients: %w", err)
+	}
+
+	waitErr := k8sclient.WaitForListCondition(ctx, dyn, gvr, ns.Namespace, listOpts, k8sclient.ListPredicate(pred), scaleTimeout(timeout))
+	if waitErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	ns.Reporter.recordStep(desc, fmt.Sprintf("watch %s label=%s", gvr.Resource, listOpts.LabelSelector), start, "", waitErr, testLogDir)
+	return waitErr
+}
+
+// WaitForDatacenterToHaveNoPodsD is a time.Duration-typed equivalent of
+// WaitForDatacenterToHaveNoPods.
+func (ns *NsWrapper) WaitForDatacenterToHaveNoPodsD(dcName string, timeout time.Duration) {
+	step := "checking that no dc pods remain"
+	listOpts := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("cassandra.datastax.com/datacenter=%s", dcName),
+	}
+	waitErr := ns.waitForListCondition(context.Background(), step, podGVR, listOpts,
+		func(items []unstructured.Unstructured) (bool, error) {
+			return len(items) == 0, nil
+		}, timeout)
+	Expect(waitErr).ToNot(HaveOccurred())
+}
+
+func (ns *NsWrapper) WaitForDatacenterToHaveNoPods(dcName string) {
+	ns.WaitForDatacenterToHaveNoPodsD(dcName, 300*time.Second)
+}
+
+// WaitForDatacenterOperatorProgressD is a time.Duration-typed equivalent of
+// WaitForDatacenterOperatorProgress.
+func (ns *NsWrapper) WaitForDatacenterOperatorProgressD(dcName string, progressValue string, timeout time.Duration) {
+	step := fmt.Sprintf("checking the cassandra operator progress status is set to %s", progressValue)
+	waitErr := ns.waitForCondition(context.Background(), step, cassandraDatacenterGVR, dcName,
+		func(obj runtime.Object) (bool, error) {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return false, nil
+			}
+			current, _, err := unstructured.NestedString(u.Object, "status", "cassandraOperatorProgress")
+			if err != nil {
+				return false, err
+			}
+			return current == progressValue, nil
+		}, timeout)
+	Expect(waitErr).ToNot(HaveOccurred())
+}
+
+func (ns *NsWrapper) WaitForDatacenterOperatorProgress(dcName string, progressValue string, timeoutSeconds int) {
+	ns.WaitForDatacenterOperatorProgressD(dcName, progressValue, time.Duration(timeoutSeconds)*time.Second)
+}
+
+// WaitForDatacenterReadyPodCountD is a time.Duration-typed equivalent of
+// WaitForDatacenterReadyPodCount.
+func (ns *NsWrapper) WaitForDatacenterReadyPodCountD(dcName string, count int, timeout time.Duration) {
+	step := "waiting for the node to become ready"
+	listOpts := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("cassandra.datastax.com/datacenter=%s", dcName),
+		FieldSelector: "status.phase=Running",
+	}
+	waitErr := ns.waitForListCondition(context.Background(), step, podGVR, listOpts,
+		func(items []unstructured.Unstructured) (bool, error) {
+			if len(items) != count {
+				return false, nil
+			}
+			for _, item := range items {
+				statuses, found, err := unstructured.NestedSlice(item.Object, "status", "containerStatuses")
+				if err != nil {
+					return false, err
+				}
+				if !found || len(statuses) == 0 {
+					return false, nil
+				}
+				first, ok := statuses[0].(map[string]interface{})
+				if !ok || first["ready"] != true {
+					return false, nil
+				}
+			}
+			return true, nil
+		}, timeout)
+	Expect(waitErr).ToNot(HaveOccurred())
+}
+
+func (ns *NsWrapper) WaitForDatacenterReadyPodCount(dcName string, count int) {
+	ns.WaitForDatacenterReadyPodCountD(dcName, count, time.Duration(count*400)*time.Second)
+}
+
+// waitForPodNodeState is the shared, non-Ginkgo-aware primitive behind
+// WaitForPodStarted/WaitForPodNotStarted: it blocks until podName's
+// cassandra.datastax.com/node-state label does (or doesn't) read "Started".
+// It deliberately avoids ginkgo.By/Expect so it's safe to call from a
+// worker goroutine when waiting on many pods concurrently, e.g. from
+// ExecOnAllDatacenterPods-driven fan-out helpers.
+func (ns *NsWrapper) waitForPodNodeState(podName string, wantStarted bool, timeout time.Duration) error {
+	_, dyn, err := diagClients()
+	if err != nil {
+		return fmt.Errorf("waitForPodNodeState: could not build client-go clients: %w", err)
+	}
+
+	return k8sclient.WaitForCondition(context.Background(), dyn, podGVR, ns.Namespace, podName,
+		func(obj runtime.Object) (bool, error) {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				// Deleted entirely also satisfies "not started".
+				return !wantStarted, nil
+			}
+			isStarted := u.GetLabels()["cassandra.datastax.com/node-state"] == "Started"
+			return isStarted == wantStarted, nil
+		}, scaleTimeout(timeout))
+}
+
+// WaitForPodNotStartedD is a time.Duration-typed equivalent of
+// WaitForPodNotStarted.
+func (ns *NsWrapper) WaitForPodNotStartedD(podName string, timeout time.Duration) {
+	step := "verify that the pod is no longer marked as started"
+	ginkgo.By(step)
+	testLogDir := ns.genTestLogDir(step)
+	waitErr := ns.waitForPodNodeState(podName, false, timeout)
+	if waitErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	Expect(waitErr).ToNot(HaveOccurred())
+}
+
+func (ns *NsWrapper) WaitForPodNotStarted(podName string) {
+	ns.WaitForPodNotStartedD(podName, 60*time.Second)
+}
+
+// WaitForPodStartedD is a time.Duration-typed equivalent of
+// WaitForPodStarted.
+func (ns *NsWrapper) WaitForPodStartedD(podName string, timeout time.Duration) {
+	step := "verify that the pod is marked as started"
+	ginkgo.By(step)
+	testLogDir := ns.genTestLogDir(step)
+	waitErr := ns.waitForPodNodeState(podName, true, timeout)
+	if waitErr != nil {
+		ns.DumpDiagnosticsOnFailure(testLogDir)
+	}
+	Expect(waitErr).ToNot(HaveOccurred())
+}
+
+func (ns *NsWrapper) WaitForPodStarted(podName string) {
+	ns.WaitForPodStartedD(podName, 60*time.Second)
+}
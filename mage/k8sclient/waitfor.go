@@ -0,0 +1,235 @@
+// Copyright DataStax, Inc.
+// Please see the included license file for details.
+
+// Package k8sclient replaces the e2e suite's old pattern of forking
+// `kubectl get` in a tight poll loop with a single watch connection per
+// wait. Polling was the dominant wall-clock cost of the suite and every
+// transient apiserver hiccup during a poll surfaced as a noisy, unrelated
+// error; watching the object (or the label-selected set of objects) and
+// evaluating a predicate on every delta fixes both.
+package k8sclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// Predicate reports whether obj satisfies a wait condition. obj is nil
+// when the watched object has been deleted.
+type Predicate func(obj runtime.Object) (bool, error)
+
+// ListPredicate reports whether the current set of label-selected objects
+// satisfies a wait condition.
+type ListPredicate func(items []unstructured.Unstructured) (bool, error)
+
+// WaitForCondition blocks until pred reports the named object as
+// satisfied, ctx is cancelled, or timeout elapses. It performs a single
+// Get to catch conditions already satisfied, then watches for further
+// deltas rather than re-polling.
+func WaitForCondition(ctx context.Context, dyn dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string, pred Predicate, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := dyn.Resource(gvr).Namespace(namespace)
+
+	var resourceVersion string
+	if current, err := client.Get(name, metav1.GetOptions{}); err == nil {
+		resourceVersion = current.GetResourceVersion()
+		if ok, perr := pred(current); perr != nil {
+			return perr
+		} else if ok {
+			return nil
+		}
+	} else if apierrors.IsNotFound(err) {
+		if ok, perr := pred(nil); perr != nil {
+			return perr
+		} else if ok {
+			return nil
+		}
+	}
+
+	// The apiserver closes idle watches well before a multi-minute wait
+	// completes, so a closed channel isn't a hard error -- reconnect from
+	// the last-seen ResourceVersion and keep evaluating pred until ctx
+	// expires.
+	for {
+		watcher, err := client.Watch(metav1.ListOptions{ResourceVersion: resourceVersion})
+		if err != nil {
+			return fmt.Errorf("could not watch %s/%s in namespace %s: %w", gvr.Resource, name, namespace, err)
+		}
+
+		satisfied, lastRV, err := watchForCondition(ctx, watcher, gvr, name, pred)
+		watcher.Stop()
+		if satisfied || err != nil {
+			return err
+		}
+		if lastRV != "" {
+			resourceVersion = lastRV
+		}
+	}
+}
+
+// watchForCondition drains watcher until pred is satisfied, ctx expires, or
+// the watch channel closes. A closed channel is reported via satisfied=false,
+// err=nil so the caller reconnects instead of treating it as a failure.
+func watchForCondition(ctx context.Context, watcher watch.Interface, gvr schema.GroupVersionResource, name string, pred Predicate) (satisfied bool, lastRV string, err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return true, lastRV, fmt.Errorf("timed out waiting for condition on %s/%s: %w", gvr.Resource, name, ctx.Err())
+		case event, open := <-watcher.ResultChan():
+			if !open {
+				return false, lastRV, nil
+			}
+
+			u, ok := event.Object.(*unstructured.Unstructured)
+			if !ok || u.GetName() != name {
+				continue
+			}
+			lastRV = u.GetResourceVersion()
+
+			if event.Type == watch.Deleted {
+				if ok, perr := pred(nil); perr != nil {
+					return true, lastRV, perr
+				} else if ok {
+					return true, lastRV, nil
+				}
+				continue
+			}
+
+			if ok, perr := pred(u); perr != nil {
+				return true, lastRV, perr
+			} else if ok {
+				return true, lastRV, nil
+			}
+		}
+	}
+}
+
+// WaitForListCondition blocks until pred reports the full, current set of
+// objects matching listOpts as satisfied, ctx is cancelled, or timeout
+// elapses. It maintains a small local cache keyed by object name, seeded
+// from an initial List and kept current by a Watch, so pred always sees
+// the whole set rather than a single delta -- this is what lets callers
+// express conditions like "there are no pods left" or "N pods are ready".
+func WaitForListCondition(ctx context.Context, dyn dynamic.Interface, gvr schema.GroupVersionResource, namespace string, listOpts metav1.ListOptions, pred ListPredicate, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := dyn.Resource(gvr).Namespace(namespace)
+
+	list, err := client.List(listOpts)
+	if err != nil {
+		return fmt.Errorf("could not list %s in namespace %s: %w", gvr.Resource, namespace, err)
+	}
+
+	cache := &objectCache{items: map[string]unstructured.Unstructured{}}
+	for _, item := range list.Items {
+		cache.put(item)
+	}
+	if ok, perr := pred(cache.values()); perr != nil {
+		return perr
+	} else if ok {
+		return nil
+	}
+
+	resourceVersion := list.GetResourceVersion()
+
+	// As with WaitForCondition, the apiserver can close this watch well
+	// before a multi-minute wait completes -- reconnect from the
+	// last-seen ResourceVersion instead of failing on channel close.
+	for {
+		watchOpts := listOpts
+		watchOpts.ResourceVersion = resourceVersion
+		watcher, err := client.Watch(watchOpts)
+		if err != nil {
+			return fmt.Errorf("could not watch %s in namespace %s: %w", gvr.Resource, namespace, err)
+		}
+
+		satisfied, lastRV, err := watchForListCondition(ctx, watcher, gvr, cache, pred)
+		watcher.Stop()
+		if satisfied || err != nil {
+			return err
+		}
+		if lastRV != "" {
+			resourceVersion = lastRV
+		}
+	}
+}
+
+// watchForListCondition drains watcher, keeping cache current and
+// evaluating pred on every delta, until pred is satisfied, ctx expires, or
+// the watch channel closes. A closed channel is reported via
+// satisfied=false, err=nil so the caller reconnects instead of treating it
+// as a failure.
+func watchForListCondition(ctx context.Context, watcher watch.Interface, gvr schema.GroupVersionResource, cache *objectCache, pred ListPredicate) (satisfied bool, lastRV string, err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return true, lastRV, fmt.Errorf("timed out waiting for list condition on %s: %w", gvr.Resource, ctx.Err())
+		case event, open := <-watcher.ResultChan():
+			if !open {
+				return false, lastRV, nil
+			}
+
+			u, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			lastRV = u.GetResourceVersion()
+
+			switch event.Type {
+			case watch.Deleted:
+				cache.delete(u.GetName())
+			default:
+				cache.put(*u)
+			}
+
+			if ok, perr := pred(cache.values()); perr != nil {
+				return true, lastRV, perr
+			} else if ok {
+				return true, lastRV, nil
+			}
+		}
+	}
+}
+
+// objectCache is a minimal name-keyed cache, standing in for a full
+// client-go SharedIndexInformer store -- all these waits need is "the
+// current set of objects matching a selector", not indexing or resync.
+type objectCache struct {
+	mu    sync.Mutex
+	items map[string]unstructured.Unstructured
+}
+
+func (c *objectCache) put(obj unstructured.Unstructured) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[obj.GetName()] = obj
+}
+
+func (c *objectCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, name)
+}
+
+func (c *objectCache) values() []unstructured.Unstructured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]unstructured.Unstructured, 0, len(c.items))
+	for _, item := range c.items {
+		out = append(out, item)
+	}
+	return out
+}